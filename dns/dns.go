@@ -0,0 +1,293 @@
+package dns
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/giantswarm/exporterkit/histogramvec"
+	"github.com/giantswarm/microerror"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/giantswarm/net-exporter/prober"
+)
+
+const (
+	namespace = "dns"
+
+	bucketStart  = 0.001
+	bucketFactor = 2
+	numBuckets   = 15
+
+	defaultScrapeTimeout = 5 * time.Second
+
+	nativeHistogramBucketFactor   = 1.1
+	nativeHistogramMaxBucketCount = 100
+)
+
+// HistogramMode selects how the latency histogram is exposed.
+type HistogramMode string
+
+const (
+	// HistogramModeClassic emits the original fixed-bucket histogram via
+	// MustNewConstHistogram, as net-exporter always has.
+	HistogramModeClassic HistogramMode = "classic"
+	// HistogramModeNative emits a Prometheus native (sparse) histogram only.
+	HistogramModeNative HistogramMode = "native"
+	// HistogramModeBoth emits a native histogram alongside the classic fixed
+	// buckets, from the same metric, to ease migrating dashboards and alerts.
+	HistogramModeBoth HistogramMode = "both"
+)
+
+// Config provides the necessary configuration for creating a Collector.
+type Config struct {
+	Logger     *slog.Logger
+	Prober     prober.Prober
+	Registerer prometheus.Registerer
+
+	// HistogramMode selects how the latency histogram is exposed. It
+	// defaults to HistogramModeClassic when empty.
+	HistogramMode HistogramMode
+
+	// ScrapeTimeout bounds how long a single host lookup may take during a
+	// Collect call. It defaults to defaultScrapeTimeout when zero.
+	ScrapeTimeout time.Duration
+
+	Hosts  []string
+	Module string
+}
+
+// Collector implements the Collector interface, exposing DNS resolution latency information.
+type Collector struct {
+	logger *slog.Logger
+	prober prober.Prober
+
+	hosts  []string
+	module string
+
+	histogramMode HistogramMode
+
+	scrapeTimeout time.Duration
+
+	// scrapeID is used to identify logs for a Collect call.
+	scrapeID uint64
+
+	latencyHistogramVec  *histogramvec.HistogramVec
+	latencyHistogramDesc *prometheus.Desc
+	nativeLatencyVec     *prometheus.HistogramVec
+	probeSuccessDesc     *prometheus.Desc
+	lookupDurationDesc   *prometheus.Desc
+
+	errorCount       prometheus.Counter
+	lookupErrorCount *prometheus.CounterVec
+}
+
+// New creates a Collector, given a Config.
+func New(config Config) (*Collector, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Prober == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Prober must not be empty", config)
+	}
+	if config.Registerer == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Registerer must not be empty", config)
+	}
+	if len(config.Hosts) == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Hosts must not be empty", config)
+	}
+	if config.Module == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Module must not be empty", config)
+	}
+
+	histogramMode := config.HistogramMode
+	if histogramMode == "" {
+		histogramMode = HistogramModeClassic
+	}
+	if histogramMode != HistogramModeClassic && histogramMode != HistogramModeNative && histogramMode != HistogramModeBoth {
+		return nil, microerror.Maskf(invalidConfigError, "%T.HistogramMode must be one of classic, native, both", config)
+	}
+
+	scrapeTimeout := config.ScrapeTimeout
+	if scrapeTimeout == 0 {
+		scrapeTimeout = defaultScrapeTimeout
+	}
+
+	var err error
+
+	var latencyHistogramVec *histogramvec.HistogramVec
+	var latencyHistogramDesc *prometheus.Desc
+	var nativeLatencyVec *prometheus.HistogramVec
+
+	if histogramMode == HistogramModeClassic {
+		c := histogramvec.Config{
+			BucketLimits: prometheus.ExponentialBuckets(bucketStart, bucketFactor, numBuckets),
+		}
+		latencyHistogramVec, err = histogramvec.New(c)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		latencyHistogramDesc = prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "latency_seconds"),
+			"Histogram of latency of DNS lookups.",
+			[]string{"host"},
+			nil,
+		)
+	} else {
+		opts := prometheus.HistogramOpts{
+			Name:                           prometheus.BuildFQName(namespace, "", "latency_seconds"),
+			Help:                           "Histogram of latency of DNS lookups.",
+			NativeHistogramBucketFactor:    nativeHistogramBucketFactor,
+			NativeHistogramMaxBucketNumber: nativeHistogramMaxBucketCount,
+		}
+		if histogramMode == HistogramModeBoth {
+			opts.Buckets = prometheus.ExponentialBuckets(bucketStart, bucketFactor, numBuckets)
+		}
+
+		nativeLatencyVec = prometheus.NewHistogramVec(opts, []string{"host"})
+		config.Registerer.MustRegister(nativeLatencyVec)
+	}
+
+	errorCount := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: prometheus.BuildFQName(namespace, "", "error_total"),
+		Help: "Total number of internal errors.",
+	})
+	lookupErrorCount := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, "", "lookup_error_total"),
+			Help: "Total number of errors looking up hosts.",
+		},
+		[]string{"host"},
+	)
+	config.Registerer.MustRegister(errorCount)
+	config.Registerer.MustRegister(lookupErrorCount)
+
+	collector := &Collector{
+		logger: config.Logger,
+		prober: config.Prober,
+
+		hosts:  config.Hosts,
+		module: config.Module,
+
+		histogramMode: histogramMode,
+		scrapeTimeout: scrapeTimeout,
+
+		latencyHistogramVec:  latencyHistogramVec,
+		latencyHistogramDesc: latencyHistogramDesc,
+		nativeLatencyVec:     nativeLatencyVec,
+		probeSuccessDesc: prometheus.NewDesc(
+			prometheus.BuildFQName("probe", "", "success"),
+			"Displays whether or not the probe was a success.",
+			[]string{"host", "module"},
+			nil,
+		),
+		lookupDurationDesc: prometheus.NewDesc(
+			prometheus.BuildFQName("probe", "", "dns_lookup_time_seconds"),
+			"Returns the time taken for probe dns lookup in seconds.",
+			[]string{"host", "module"},
+			nil,
+		),
+
+		errorCount:       errorCount,
+		lookupErrorCount: lookupErrorCount,
+	}
+
+	return collector, nil
+}
+
+// Describe implements the Describe method of the Collector interface. In
+// HistogramModeNative and HistogramModeBoth, the latency histogram is
+// registered directly with config.Registerer by New, so it is not described
+// here.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	if c.histogramMode == HistogramModeClassic {
+		ch <- c.latencyHistogramDesc
+	}
+	ch <- c.probeSuccessDesc
+	ch <- c.lookupDurationDesc
+}
+
+// Collect implements the Collect method of the Collector interface.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	atomic.AddUint64(&c.scrapeID, 1)
+
+	scrapingStart := time.Now()
+	c.logger.Info("collecting metrics", "scrapeID", c.scrapeID)
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	probeSuccess := map[string]bool{}
+	lookupDuration := map[string]float64{}
+
+	for _, host := range c.hosts {
+		wg.Add(1)
+
+		go func(host string) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), c.scrapeTimeout)
+			defer cancel()
+
+			success, metrics, err := c.prober.Probe(ctx, host)
+			if err != nil {
+				c.logger.Error("could not look up host", "host", host, "scrapeID", c.scrapeID, "stack", err.Error())
+				c.errorCount.Inc()
+				return
+			}
+			if !success {
+				c.logger.Error("lookup of host failed", "host", host, "scrapeID", c.scrapeID)
+				c.lookupErrorCount.WithLabelValues(host).Inc()
+			}
+
+			mutex.Lock()
+			probeSuccess[host] = success
+			mutex.Unlock()
+
+			if elapsed, ok := metrics["probe_dns_lookup_time_seconds"]; ok {
+				c.logger.Info("looked up host", "host", host, "scrapeTime", elapsed, "scrapeID", c.scrapeID)
+
+				if c.histogramMode == HistogramModeClassic {
+					c.latencyHistogramVec.Add(host, elapsed)
+				} else {
+					c.nativeLatencyVec.WithLabelValues(host).Observe(elapsed)
+				}
+
+				mutex.Lock()
+				lookupDuration[host] = elapsed
+				mutex.Unlock()
+			}
+		}(host)
+	}
+
+	wg.Wait()
+
+	if c.histogramMode == HistogramModeClassic {
+		c.latencyHistogramVec.Ensure(c.hosts)
+
+		for host, histogram := range c.latencyHistogramVec.Histograms() {
+			ch <- prometheus.MustNewConstHistogram(
+				c.latencyHistogramDesc,
+				histogram.Count(), histogram.Sum(), histogram.Buckets(),
+				host,
+			)
+		}
+	}
+
+	for host, success := range probeSuccess {
+		value := 0.0
+		if success {
+			value = 1.0
+		}
+		ch <- prometheus.MustNewConstMetric(c.probeSuccessDesc, prometheus.GaugeValue, value, host, c.module)
+	}
+
+	for host, elapsed := range lookupDuration {
+		ch <- prometheus.MustNewConstMetric(c.lookupDurationDesc, prometheus.GaugeValue, elapsed, host, c.module)
+	}
+
+	scrapingElapsed := time.Since(scrapingStart)
+	c.logger.Info("collected metrics", "scrapeID", c.scrapeID, "scrapeTime", scrapingElapsed.Seconds())
+}
@@ -1,21 +1,26 @@
 package main
 
 import (
+	"crypto/subtle"
 	"flag"
 	"fmt"
+	"log/slog"
 	"net"
+	"net/http"
 	"os"
 	"strings"
 	"time"
 
-	"github.com/giantswarm/exporterkit"
-	"github.com/giantswarm/micrologger"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
 	"github.com/giantswarm/net-exporter/dns"
+	"github.com/giantswarm/net-exporter/mesh"
 	"github.com/giantswarm/net-exporter/network"
+	"github.com/giantswarm/net-exporter/prober"
+	"github.com/giantswarm/net-exporter/targets"
 )
 
 var (
@@ -23,6 +28,27 @@ var (
 	namespace string
 	port      string
 	service   string
+
+	logLevel  string
+	logFormat string
+
+	configFile string
+	dnsModule  string
+	tcpModule  string
+
+	histogramMode string
+
+	meshEnabled        bool
+	meshPeerPort       string
+	meshLeaseName      string
+	meshLeaseNamespace string
+
+	webListenAddress   string
+	webAuthBearerToken string
+	webAuthUsername    string
+	webAuthPassword    string
+
+	scrapeTimeout time.Duration
 )
 
 func init() {
@@ -30,6 +56,83 @@ func init() {
 	flag.StringVar(&namespace, "namespace", "monitoring", "Namespace of net-exporter service")
 	flag.StringVar(&port, "port", "8000", "Port of net-exporter service")
 	flag.StringVar(&service, "service", "net-exporter", "Name of net-exporter service")
+
+	flag.StringVar(&logLevel, "log.level", "info", "Minimum level of logs to emit (debug, info, warn, error)")
+	flag.StringVar(&logFormat, "log.format", "logfmt", "Log output format (logfmt, json)")
+
+	flag.StringVar(&configFile, "config.file", "/etc/net-exporter/config.yml", "Path to the probe configuration file")
+	flag.StringVar(&dnsModule, "dns.module", "dns_lookup", "Name of the probe module used to resolve --hosts")
+	flag.StringVar(&tcpModule, "tcp.module", "tcp_connect", "Name of the probe module used to dial discovered endpoints")
+
+	flag.StringVar(&histogramMode, "histogram.native", "classic", "How to expose latency histograms: classic, native, or both")
+
+	flag.BoolVar(&meshEnabled, "mesh.enabled", false, "Enable the opt-in pod-to-pod mesh latency matrix")
+	flag.StringVar(&meshPeerPort, "mesh.peer-port", "8001", "Port this instance serves /peers/latencies on for other net-exporter pods")
+	flag.StringVar(&meshLeaseName, "mesh.lease-name", "net-exporter-mesh", "Name of the Lease used to elect the mesh aggregator")
+	flag.StringVar(&meshLeaseNamespace, "mesh.lease-namespace", "monitoring", "Namespace of the Lease used to elect the mesh aggregator")
+
+	flag.StringVar(&webListenAddress, "web.listen-address", "0.0.0.0:8000", "Address net-exporter serves /metrics and /healthz on")
+	flag.StringVar(&webAuthBearerToken, "web.auth.bearer-token", "", "If set, require this bearer token on /metrics")
+	flag.StringVar(&webAuthUsername, "web.auth.username", "", "If set along with --web.auth.password, require HTTP basic auth on /metrics")
+	flag.StringVar(&webAuthPassword, "web.auth.password", "", "If set along with --web.auth.username, require HTTP basic auth on /metrics")
+
+	flag.DurationVar(&scrapeTimeout, "scrape.timeout", 5*time.Second, "How long a single host probe may take during a scrape")
+}
+
+// newSlogLogger builds a *slog.Logger whose handler is chosen by the given
+// level and format flags.
+func newSlogLogger(level, format string) (*slog.Logger, error) {
+	var slogLevel slog.Level
+	if err := slogLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log.level %q: %w", level, err)
+	}
+
+	opts := &slog.HandlerOptions{
+		Level: slogLevel,
+	}
+
+	var handler slog.Handler
+	switch format {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	case "logfmt":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	default:
+		return nil, fmt.Errorf("invalid log.format %q: must be logfmt or json", format)
+	}
+
+	return slog.New(handler), nil
+}
+
+// metricsAuth wraps handler so it rejects requests that do not present the
+// configured bearer token or basic auth credentials. If neither is
+// configured, it returns handler unwrapped.
+func metricsAuth(handler http.Handler, bearerToken, username, password string) http.Handler {
+	switch {
+	case bearerToken != "":
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(bearerToken)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			handler.ServeHTTP(w, r)
+		})
+	case username != "" && password != "":
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotUsername, gotPassword, ok := r.BasicAuth()
+			if !ok ||
+				subtle.ConstantTimeCompare([]byte(gotUsername), []byte(username)) != 1 ||
+				subtle.ConstantTimeCompare([]byte(gotPassword), []byte(password)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="net-exporter"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			handler.ServeHTTP(w, r)
+		})
+	default:
+		return handler
+	}
 }
 
 func main() {
@@ -39,14 +142,15 @@ func main() {
 
 	flag.Parse()
 
+	if (webAuthUsername == "") != (webAuthPassword == "") {
+		panic("--web.auth.username and --web.auth.password must be set together")
+	}
+
 	var err error
 
-	var logger micrologger.Logger
-	{
-		logger, err = micrologger.New(micrologger.Config{})
-		if err != nil {
-			panic(fmt.Sprintf("%#v\n", err))
-		}
+	logger, err := newSlogLogger(logLevel, logFormat)
+	if err != nil {
+		panic(fmt.Sprintf("%#v\n", err))
 	}
 
 	var kubernetesClient kubernetes.Interface
@@ -63,14 +167,42 @@ func main() {
 		}
 	}
 
+	probeConfig, err := prober.LoadConfigFile(configFile)
+	if err != nil {
+		panic(fmt.Sprintf("%#v\n", err))
+	}
+
+	dialer := &net.Dialer{
+		KeepAlive: -1 * time.Second,
+		Timeout:   5 * time.Second,
+	}
+
+	registry := prometheus.NewRegistry()
+
 	var dnsCollector prometheus.Collector
 	{
+		module, err := probeConfig.Module(dnsModule)
+		if err != nil {
+			panic(fmt.Sprintf("%#v\n", err))
+		}
+
+		dnsProber, err := prober.New(module, dialer)
+		if err != nil {
+			panic(fmt.Sprintf("%#v\n", err))
+		}
+
 		splitHosts := strings.Split(hosts, ",")
 
 		c := dns.Config{
-			Logger: logger,
+			Logger:     logger,
+			Prober:     dnsProber,
+			Registerer: registry,
+
+			HistogramMode: dns.HistogramMode(histogramMode),
+			ScrapeTimeout: scrapeTimeout,
 
-			Hosts: splitHosts,
+			Hosts:  splitHosts,
+			Module: dnsModule,
 		}
 
 		dnsCollector, err = dns.New(c)
@@ -79,16 +211,29 @@ func main() {
 		}
 	}
 
-	var networkCollector prometheus.Collector
+	var networkCollector *network.Collector
 	{
+		module, err := probeConfig.Module(tcpModule)
+		if err != nil {
+			panic(fmt.Sprintf("%#v\n", err))
+		}
+
+		tcpProber, err := prober.New(module, dialer)
+		if err != nil {
+			panic(fmt.Sprintf("%#v\n", err))
+		}
+
 		c := network.Config{
-			Dialer: &net.Dialer{
-				KeepAlive: -1 * time.Second,
-				Timeout:   5 * time.Second,
-			},
 			KubernetesClient: kubernetesClient,
 			Logger:           logger,
+			Prober:           tcpProber,
+			ProberModule:     module,
+			Registerer:       registry,
+
+			HistogramMode: network.HistogramMode(histogramMode),
+			ScrapeTimeout: scrapeTimeout,
 
+			Module:    tcpModule,
 			Namespace: namespace,
 			Port:      port,
 			Service:   service,
@@ -100,21 +245,117 @@ func main() {
 		}
 	}
 
-	var exporter *exporterkit.Exporter
-	{
-		c := exporterkit.Config{
-			Collectors: []prometheus.Collector{
-				dnsCollector,
-				networkCollector,
+	registry.MustRegister(dnsCollector, networkCollector)
+
+	if len(probeConfig.Targets) > 0 {
+		probes := make([]targets.Probe, 0, len(probeConfig.Targets))
+		for _, t := range probeConfig.Targets {
+			module, err := probeConfig.Module(t.Module)
+			if err != nil {
+				panic(fmt.Sprintf("%#v\n", err))
+			}
+
+			targetProber, err := prober.New(module, dialer)
+			if err != nil {
+				panic(fmt.Sprintf("%#v\n", err))
+			}
+
+			probes = append(probes, targets.Probe{
+				Target: t.Target,
+				Module: t.Module,
+				Prober: targetProber,
+			})
+		}
+
+		c := targets.Config{
+			Logger:        logger,
+			Registerer:    registry,
+			ScrapeTimeout: scrapeTimeout,
+
+			Probes: probes,
+		}
+
+		targetCollector, err := targets.New(c)
+		if err != nil {
+			panic(fmt.Sprintf("%#v\n", err))
+		}
+
+		registry.MustRegister(targetCollector)
+	}
+
+	if meshEnabled {
+		podName := os.Getenv("POD_NAME")
+		nodeName := os.Getenv("NODE_NAME")
+
+		c := mesh.Config{
+			KubernetesClient: kubernetesClient,
+			Logger:           logger,
+
+			Peers: func() []mesh.Peer {
+				peers := make([]mesh.Peer, 0)
+				for _, peer := range networkCollector.Peers() {
+					peers = append(peers, mesh.Peer{
+						PodName:  peer.PodName,
+						NodeName: peer.NodeName,
+						Address:  net.JoinHostPort(strings.Split(peer.Address, ":")[0], meshPeerPort),
+					})
+				}
+				return peers
+			},
+			Report: func() mesh.Report {
+				report := mesh.Report{
+					SourcePod:  podName,
+					SourceNode: nodeName,
+				}
+				for _, peer := range networkCollector.Peers() {
+					latency, ok := networkCollector.LatestLatencies()[peer.Address]
+					if !ok {
+						continue
+					}
+					report.Measurements = append(report.Measurements, mesh.Measurement{
+						TargetPod:      peer.PodName,
+						TargetNode:     peer.NodeName,
+						LatencySeconds: latency,
+					})
+				}
+				return report
 			},
-			Logger: logger,
+
+			LeaseName:      meshLeaseName,
+			LeaseNamespace: meshLeaseNamespace,
+			PodName:        podName,
 		}
 
-		exporter, err = exporterkit.New(c)
+		meshCollector, err := mesh.New(c)
 		if err != nil {
 			panic(fmt.Sprintf("%#v\n", err))
 		}
+
+		registry.MustRegister(meshCollector)
+
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/peers/latencies", meshCollector.Handler())
+
+			if err := http.ListenAndServe(net.JoinHostPort("", meshPeerPort), mux); err != nil {
+				logger.Error("mesh peer server exited", "stack", err.Error())
+			}
+		}()
 	}
 
-	exporter.Run()
+	metricsHandler := metricsAuth(
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}),
+		webAuthBearerToken, webAuthUsername, webAuthPassword,
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	logger.Info("starting metrics server", "address", webListenAddress)
+	if err := http.ListenAndServe(webListenAddress, mux); err != nil {
+		panic(fmt.Sprintf("%#v\n", err))
+	}
 }
@@ -0,0 +1,237 @@
+// Package mesh adds an opt-in full pod-to-pod latency matrix on top of
+// network.Collector's star topology. Each net-exporter instance serves its
+// own measurements over HTTP; the instance elected leader of a Kubernetes
+// lease polls every peer and re-emits the N×N matrix, so only one instance
+// ever produces network_mesh_latency_seconds series for a given pair.
+package mesh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	httpTimeout   = 5 * time.Second
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// Peer identifies another net-exporter instance to poll for its own
+// measurements when this instance is the elected aggregator.
+type Peer struct {
+	PodName  string
+	NodeName string
+	Address  string
+}
+
+// Measurement is one target's latency, as reported by a peer's
+// /peers/latencies endpoint.
+type Measurement struct {
+	TargetPod      string  `json:"target_pod"`
+	TargetNode     string  `json:"target_node"`
+	LatencySeconds float64 `json:"latency_seconds"`
+}
+
+// Report is what a net-exporter instance serves from /peers/latencies: its
+// own most recent per-target latency measurements.
+type Report struct {
+	SourcePod    string        `json:"source_pod"`
+	SourceNode   string        `json:"source_node"`
+	Measurements []Measurement `json:"measurements"`
+}
+
+// Config provides the necessary configuration for creating a Collector.
+type Config struct {
+	KubernetesClient kubernetes.Interface
+	Logger           *slog.Logger
+
+	// Peers returns the current set of net-exporter peers to poll when this
+	// instance is the elected aggregator.
+	Peers func() []Peer
+	// Report returns this instance's own most recent measurements, served
+	// from /peers/latencies.
+	Report func() Report
+
+	LeaseName      string
+	LeaseNamespace string
+	PodName        string
+}
+
+// Collector implements the Collector interface, exposing a full pod-to-pod
+// latency matrix. Only the instance elected leader of LeaseName emits the
+// matrix; every other instance reports nothing.
+type Collector struct {
+	logger *slog.Logger
+
+	peers  func() []Peer
+	report func() Report
+
+	httpClient *http.Client
+
+	isLeader int32
+
+	matrixDesc *prometheus.Desc
+}
+
+// New creates a Collector, given a Config, and starts the leader election
+// loop that decides whether this instance emits the matrix.
+func New(config Config) (*Collector, error) {
+	if config.KubernetesClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.KubernetesClient must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Peers == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Peers must not be empty", config)
+	}
+	if config.Report == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Report must not be empty", config)
+	}
+	if config.LeaseName == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.LeaseName must not be empty", config)
+	}
+	if config.LeaseNamespace == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.LeaseNamespace must not be empty", config)
+	}
+	if config.PodName == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.PodName must not be empty", config)
+	}
+
+	collector := &Collector{
+		logger: config.Logger,
+
+		peers:  config.Peers,
+		report: config.Report,
+
+		httpClient: &http.Client{Timeout: httpTimeout},
+
+		matrixDesc: prometheus.NewDesc(
+			prometheus.BuildFQName("network", "mesh", "latency_seconds"),
+			"Latency between net-exporter pods, as a full mesh matrix. Only populated by the elected aggregator.",
+			[]string{"source_pod", "source_node", "target_pod", "target_node"},
+			nil,
+		),
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      config.LeaseName,
+			Namespace: config.LeaseNamespace,
+		},
+		Client: config.KubernetesClient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: config.PodName,
+		},
+	}
+
+	go leaderelection.RunOrDie(context.Background(), leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: leaseDuration,
+		RenewDeadline: renewDeadline,
+		RetryPeriod:   retryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				config.Logger.Info("started leading mesh aggregation", "pod", config.PodName)
+				atomic.StoreInt32(&collector.isLeader, 1)
+			},
+			OnStoppedLeading: func() {
+				config.Logger.Info("stopped leading mesh aggregation", "pod", config.PodName)
+				atomic.StoreInt32(&collector.isLeader, 0)
+			},
+		},
+	})
+
+	return collector, nil
+}
+
+// Handler serves this instance's own latest measurements as JSON, for the
+// aggregator to poll.
+func (c *Collector) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(c.report()); err != nil {
+			c.logger.Error("could not encode peer latencies", "stack", err.Error())
+		}
+	})
+}
+
+// Describe implements the Describe method of the Collector interface.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.matrixDesc
+}
+
+// Collect implements the Collect method of the Collector interface. It is a
+// no-op unless this instance currently holds the aggregator lease.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	if atomic.LoadInt32(&c.isLeader) == 0 {
+		return
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var reports []Report
+
+	for _, peer := range c.peers() {
+		wg.Add(1)
+
+		go func(peer Peer) {
+			defer wg.Done()
+
+			report, err := c.fetch(peer)
+			if err != nil {
+				c.logger.Error("could not fetch peer latencies", "peer", peer.PodName, "stack", err.Error())
+				return
+			}
+
+			mutex.Lock()
+			reports = append(reports, report)
+			mutex.Unlock()
+		}(peer)
+	}
+
+	wg.Wait()
+
+	for _, report := range reports {
+		for _, measurement := range report.Measurements {
+			ch <- prometheus.MustNewConstMetric(
+				c.matrixDesc,
+				prometheus.GaugeValue,
+				measurement.LatencySeconds,
+				report.SourcePod, report.SourceNode, measurement.TargetPod, measurement.TargetNode,
+			)
+		}
+	}
+}
+
+func (c *Collector) fetch(peer Peer) (Report, error) {
+	url := fmt.Sprintf("http://%s/peers/latencies", peer.Address)
+
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return Report{}, microerror.Mask(err)
+	}
+	defer resp.Body.Close()
+
+	var report Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return Report{}, microerror.Mask(err)
+	}
+
+	return report, nil
+}
@@ -1,18 +1,23 @@
 package network
 
 import (
+	"context"
 	"fmt"
-	"net"
+	"log/slog"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/giantswarm/exporterkit/histogramvec"
 	"github.com/giantswarm/microerror"
-	"github.com/giantswarm/micrologger"
 	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/giantswarm/net-exporter/prober"
 )
 
 const (
@@ -21,14 +26,58 @@ const (
 	bucketStart  = 0.001
 	bucketFactor = 2
 	numBuckets   = 15
+
+	resyncPeriod = 10 * time.Minute
+
+	nativeHistogramBucketFactor   = 1.1
+	nativeHistogramMaxBucketCount = 100
+
+	defaultScrapeTimeout = 5 * time.Second
+)
+
+// HistogramMode selects how latency histograms are exposed.
+type HistogramMode string
+
+const (
+	// HistogramModeClassic emits the original fixed-bucket histogram via
+	// MustNewConstHistogram, as net-exporter always has.
+	HistogramModeClassic HistogramMode = "classic"
+	// HistogramModeNative emits a Prometheus native (sparse) histogram only.
+	HistogramModeNative HistogramMode = "native"
+	// HistogramModeBoth emits a native histogram alongside the classic fixed
+	// buckets, from the same metric, to ease migrating dashboards and alerts.
+	HistogramModeBoth HistogramMode = "both"
 )
 
+// Peer identifies another endpoint of the probed Service, as discovered via
+// the Endpoints cache. It is used by the mesh collector to poll peers for
+// their own latency measurements.
+type Peer struct {
+	PodName  string
+	NodeName string
+	Address  string
+}
+
 // Config provides the necessary configuration for creating a Collector.
 type Config struct {
-	Dialer           *net.Dialer
 	KubernetesClient kubernetes.Interface
-	Logger           micrologger.Logger
-
+	Logger           *slog.Logger
+	Prober           prober.Prober
+	// ProberModule is the configuration backing Prober. It is used to format
+	// each discovered host/port pair into the address form the configured
+	// prober kind expects; see prober.TargetAddress.
+	ProberModule prober.Module
+	Registerer   prometheus.Registerer
+
+	// HistogramMode selects how the latency histogram is exposed. It
+	// defaults to HistogramModeClassic when empty.
+	HistogramMode HistogramMode
+
+	// ScrapeTimeout bounds how long a single host probe may take during a
+	// Collect call. It defaults to defaultScrapeTimeout when zero.
+	ScrapeTimeout time.Duration
+
+	Module    string
 	Namespace string
 	Port      string
 	Service   string
@@ -36,35 +85,67 @@ type Config struct {
 
 // Collector implements the Collector interface, exposing network latency information.
 type Collector struct {
-	dialer           *net.Dialer
-	kubernetesClient kubernetes.Interface
-	logger           micrologger.Logger
+	logger *slog.Logger
+	prober prober.Prober
+
+	proberModule prober.Module
 
+	module    string
 	namespace string
 	port      string
 	service   string
 
+	scrapeTimeout time.Duration
+
+	serviceInformer   cache.SharedIndexInformer
+	endpointsInformer cache.SharedIndexInformer
+
+	cacheMutex  sync.RWMutex
+	clusterIP   string
+	endpointIPs []string
+	peers       []Peer
+	lastUpdate  time.Time
+
+	latencyMutex sync.RWMutex
+	latestByHost map[string]float64
+
+	histogramMode HistogramMode
+
 	// scrapeID is used to identify logs for a Collect call.
 	scrapeID uint64
 
-	latencyHistogramVec  *histogramvec.HistogramVec
-	latencyHistogramDesc *prometheus.Desc
+	latencyHistogramVec     *histogramvec.HistogramVec
+	latencyHistogramDesc    *prometheus.Desc
+	nativeLatencyVec        *prometheus.HistogramVec
+	probeSuccessDesc        *prometheus.Desc
+	probeDurationDesc       *prometheus.Desc
+	probeHTTPStatusCodeDesc *prometheus.Desc
+	cacheSyncedDesc         *prometheus.Desc
+	lastUpdateDesc          *prometheus.Desc
 
 	errorCount     prometheus.Counter
 	dialErrorCount *prometheus.CounterVec
 }
 
-// New creates a Collector, given a Config.
+// New creates a Collector, given a Config. The Kubernetes informer caching
+// the Service and Endpoints for config.Service is started and synced before
+// New returns, so the first Collect call never blocks on the API server.
 func New(config Config) (*Collector, error) {
-	if config.Dialer == nil {
-		return nil, microerror.Maskf(invalidConfigError, "%T.Dialer must not be empty", config)
-	}
 	if config.KubernetesClient == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.KubernetesClient must not be empty", config)
 	}
 	if config.Logger == nil {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
 	}
+	if config.Prober == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Prober must not be empty", config)
+	}
+	if config.ProberModule.Prober == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ProberModule must not be empty", config)
+	}
+	if config.Registerer == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Registerer must not be empty", config)
+	}
 
 	if config.Namespace == "" {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Namespace must not be empty", config)
@@ -75,11 +156,30 @@ func New(config Config) (*Collector, error) {
 	if config.Service == "" {
 		return nil, microerror.Maskf(invalidConfigError, "%T.Service must not be empty", config)
 	}
+	if config.Module == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Module must not be empty", config)
+	}
+
+	histogramMode := config.HistogramMode
+	if histogramMode == "" {
+		histogramMode = HistogramModeClassic
+	}
+	if histogramMode != HistogramModeClassic && histogramMode != HistogramModeNative && histogramMode != HistogramModeBoth {
+		return nil, microerror.Maskf(invalidConfigError, "%T.HistogramMode must be one of classic, native, both", config)
+	}
+
+	scrapeTimeout := config.ScrapeTimeout
+	if scrapeTimeout == 0 {
+		scrapeTimeout = defaultScrapeTimeout
+	}
 
 	var err error
 
 	var latencyHistogramVec *histogramvec.HistogramVec
-	{
+	var latencyHistogramDesc *prometheus.Desc
+	var nativeLatencyVec *prometheus.HistogramVec
+
+	if histogramMode == HistogramModeClassic {
 		c := histogramvec.Config{
 			BucketLimits: prometheus.ExponentialBuckets(bucketStart, bucketFactor, numBuckets),
 		}
@@ -87,6 +187,26 @@ func New(config Config) (*Collector, error) {
 		if err != nil {
 			return nil, microerror.Mask(err)
 		}
+
+		latencyHistogramDesc = prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "latency_seconds"),
+			"Histogram of latency of network dials.",
+			[]string{"host"},
+			nil,
+		)
+	} else {
+		opts := prometheus.HistogramOpts{
+			Name:                           prometheus.BuildFQName(namespace, "", "latency_seconds"),
+			Help:                           "Histogram of latency of network dials.",
+			NativeHistogramBucketFactor:    nativeHistogramBucketFactor,
+			NativeHistogramMaxBucketNumber: nativeHistogramMaxBucketCount,
+		}
+		if histogramMode == HistogramModeBoth {
+			opts.Buckets = prometheus.ExponentialBuckets(bucketStart, bucketFactor, numBuckets)
+		}
+
+		nativeLatencyVec = prometheus.NewHistogramVec(opts, []string{"host"})
+		config.Registerer.MustRegister(nativeLatencyVec)
 	}
 
 	errorCount := prometheus.NewCounter(prometheus.CounterOpts{
@@ -100,23 +220,70 @@ func New(config Config) (*Collector, error) {
 		},
 		[]string{"host"},
 	)
-	prometheus.MustRegister(errorCount)
-	prometheus.MustRegister(dialErrorCount)
+	config.Registerer.MustRegister(errorCount)
+	config.Registerer.MustRegister(dialErrorCount)
+
+	tweakListOptions := func(options *metav1.ListOptions) {
+		options.FieldSelector = fmt.Sprintf("metadata.name=%s", config.Service)
+	}
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(
+		config.KubernetesClient,
+		resyncPeriod,
+		informers.WithNamespace(config.Namespace),
+		informers.WithTweakListOptions(tweakListOptions),
+	)
 
 	collector := &Collector{
-		dialer:           config.Dialer,
-		kubernetesClient: config.KubernetesClient,
-		logger:           config.Logger,
+		logger: config.Logger,
+		prober: config.Prober,
 
+		proberModule: config.ProberModule,
+
+		module:    config.Module,
 		namespace: config.Namespace,
 		port:      config.Port,
 		service:   config.Service,
 
-		latencyHistogramVec: latencyHistogramVec,
-		latencyHistogramDesc: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "latency_seconds"),
-			"Histogram of latency of network dials.",
-			[]string{"host"},
+		scrapeTimeout: scrapeTimeout,
+
+		serviceInformer:   informerFactory.Core().V1().Services().Informer(),
+		endpointsInformer: informerFactory.Core().V1().Endpoints().Informer(),
+
+		latestByHost: map[string]float64{},
+
+		histogramMode: histogramMode,
+
+		latencyHistogramVec:  latencyHistogramVec,
+		latencyHistogramDesc: latencyHistogramDesc,
+		nativeLatencyVec:     nativeLatencyVec,
+		probeSuccessDesc: prometheus.NewDesc(
+			prometheus.BuildFQName("probe", "", "success"),
+			"Displays whether or not the probe was a success.",
+			[]string{"host", "module"},
+			nil,
+		),
+		probeDurationDesc: prometheus.NewDesc(
+			prometheus.BuildFQName("probe", "", "duration_seconds"),
+			"Returns how long the probe took to complete in seconds.",
+			[]string{"host", "module"},
+			nil,
+		),
+		probeHTTPStatusCodeDesc: prometheus.NewDesc(
+			prometheus.BuildFQName("probe", "", "http_status_code"),
+			"Response HTTP status code, for probers that perform an HTTP request.",
+			[]string{"host", "module"},
+			nil,
+		),
+		cacheSyncedDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "endpoints_cache_synced"),
+			"Whether the Service/Endpoints informer cache has synced.",
+			nil,
+			nil,
+		),
+		lastUpdateDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "endpoints_last_update_timestamp_seconds"),
+			"Unix timestamp of the last Service/Endpoints informer cache update.",
+			nil,
 			nil,
 		),
 
@@ -124,84 +291,258 @@ func New(config Config) (*Collector, error) {
 		dialErrorCount: dialErrorCount,
 	}
 
+	collector.serviceInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: collector.updateService,
+		UpdateFunc: func(_, newObj interface{}) {
+			collector.updateService(newObj)
+		},
+		DeleteFunc: collector.clearService,
+	})
+	collector.endpointsInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: collector.updateEndpoints,
+		UpdateFunc: func(_, newObj interface{}) {
+			collector.updateEndpoints(newObj)
+		},
+		DeleteFunc: collector.clearEndpoints,
+	})
+
+	stopCh := make(chan struct{})
+	informerFactory.Start(stopCh)
+
+	for informerType, synced := range informerFactory.WaitForCacheSync(stopCh) {
+		if !synced {
+			return nil, microerror.Maskf(executionFailedError, "cache for %v never synced", informerType)
+		}
+	}
+
 	return collector, nil
 }
 
-// Describe implements the Describe method of the Collector interface.
+func (c *Collector) updateService(obj interface{}) {
+	service, ok := obj.(*corev1.Service)
+	if !ok {
+		return
+	}
+
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	c.clusterIP = service.Spec.ClusterIP
+	c.lastUpdate = time.Now()
+}
+
+func (c *Collector) clearService(obj interface{}) {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	c.clusterIP = ""
+	c.lastUpdate = time.Now()
+}
+
+func (c *Collector) updateEndpoints(obj interface{}) {
+	endpoints, ok := obj.(*corev1.Endpoints)
+	if !ok {
+		return
+	}
+
+	var endpointIPs []string
+	var peers []Peer
+	for _, subset := range endpoints.Subsets {
+		for _, address := range subset.Addresses {
+			endpointIPs = append(endpointIPs, address.IP)
+
+			peer := Peer{
+				Address: fmt.Sprintf("%v:%v", address.IP, c.port),
+			}
+			if address.TargetRef != nil {
+				peer.PodName = address.TargetRef.Name
+			}
+			if address.NodeName != nil {
+				peer.NodeName = *address.NodeName
+			}
+			peers = append(peers, peer)
+		}
+	}
+
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	c.endpointIPs = endpointIPs
+	c.peers = peers
+	c.lastUpdate = time.Now()
+}
+
+func (c *Collector) clearEndpoints(obj interface{}) {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	c.endpointIPs = nil
+	c.peers = nil
+	c.lastUpdate = time.Now()
+}
+
+// Peers returns the net-exporter endpoints currently known through the
+// Endpoints cache, for polling by the mesh aggregator.
+func (c *Collector) Peers() []Peer {
+	c.cacheMutex.RLock()
+	defer c.cacheMutex.RUnlock()
+
+	return append([]Peer(nil), c.peers...)
+}
+
+// LatestLatencies returns, keyed by "host:port", the latency of the most
+// recent probe of each host that reported probe_duration_seconds, whether or
+// not that probe succeeded. Entries are not removed when a host is no longer
+// probed.
+func (c *Collector) LatestLatencies() map[string]float64 {
+	c.latencyMutex.RLock()
+	defer c.latencyMutex.RUnlock()
+
+	latest := make(map[string]float64, len(c.latestByHost))
+	for host, seconds := range c.latestByHost {
+		latest[host] = seconds
+	}
+
+	return latest
+}
+
+// Describe implements the Describe method of the Collector interface. In
+// HistogramModeNative and HistogramModeBoth, the latency histogram is
+// registered directly with config.Registerer by New, so it is not described
+// here.
 func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- c.latencyHistogramDesc
+	if c.histogramMode == HistogramModeClassic {
+		ch <- c.latencyHistogramDesc
+	}
+	ch <- c.probeSuccessDesc
+	ch <- c.probeDurationDesc
+	ch <- c.probeHTTPStatusCodeDesc
+	ch <- c.cacheSyncedDesc
+	ch <- c.lastUpdateDesc
 }
 
 // Collect implements the Collect method of the Collector interface.
 func (c *Collector) Collect(ch chan<- prometheus.Metric) {
-	hosts := []string{}
 	atomic.AddUint64(&c.scrapeID, 1)
 
 	scrapingStart := time.Now()
-	c.logger.Log("level", "info", "message", "collecting metrics", "scrapeID", c.scrapeID)
-
-	service, err := c.kubernetesClient.CoreV1().Services(c.namespace).Get(c.service, metav1.GetOptions{})
-	if err != nil {
-		c.logger.Log("level", "error", "message", "could not get service from kubernetes api ", "scrapeID", c.scrapeID, "stack", microerror.Stack(err))
-		c.errorCount.Inc()
-		return
-	}
+	c.logger.Info("collecting metrics", "scrapeID", c.scrapeID)
 
-	c.logger.Log("level", "info", "message", "collected service", "service ", c.service, "scrapeID", c.scrapeID)
-	hosts = append(hosts, fmt.Sprintf("%v:%v", service.Spec.ClusterIP, c.port))
+	c.cacheMutex.RLock()
+	clusterIP := c.clusterIP
+	endpointIPs := append([]string(nil), c.endpointIPs...)
+	lastUpdate := c.lastUpdate
+	c.cacheMutex.RUnlock()
 
-	c.logger.Log("level", "info", "message", "connecting to kubernetes api to get endpoints", "service", c.service, "scrapeID", c.scrapeID)
-	endpoints, err := c.kubernetesClient.CoreV1().Endpoints(c.namespace).Get(c.service, metav1.GetOptions{})
-	if err != nil {
-		c.logger.Log("level", "error", "message", "could not get endpoints from kubernetes api ", "scrapeID", c.scrapeID, "stack", microerror.Stack(err))
-		c.errorCount.Inc()
-		return
+	ips := []string{}
+	if clusterIP != "" {
+		ips = append(ips, clusterIP)
 	}
+	ips = append(ips, endpointIPs...)
+
+	// hosts is used throughout as the stable "host" label/key, in "ip:port"
+	// form, regardless of what address the configured prober kind actually
+	// needs to be probed at.
+	hosts := []string{}
+	targetByHost := map[string]string{}
+	for _, ip := range ips {
+		host := fmt.Sprintf("%v:%v", ip, c.port)
 
-	c.logger.Log("level", "info", "message", "collected endpoints", "service", c.service, "scrapeID", c.scrapeID)
-	for _, endpointSubset := range endpoints.Subsets {
-		for _, address := range endpointSubset.Addresses {
-			hosts = append(hosts, fmt.Sprintf("%v:%v", address.IP, c.port))
+		target, err := prober.TargetAddress(c.proberModule, ip, c.port)
+		if err != nil {
+			c.logger.Error("could not format probe target", "host", host, "scrapeID", c.scrapeID, "stack", err.Error())
+			c.errorCount.Inc()
+			continue
 		}
+
+		hosts = append(hosts, host)
+		targetByHost[host] = target
 	}
 
 	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	probeSuccess := map[string]bool{}
+	probeMetrics := map[string]map[string]float64{}
 
 	for _, host := range hosts {
 		wg.Add(1)
 
-		go func(host string) {
+		go func(host, target string) {
 			defer wg.Done()
 
-			start := time.Now()
+			ctx, cancel := context.WithTimeout(context.Background(), c.scrapeTimeout)
+			defer cancel()
 
-			conn, err := c.dialer.Dial("tcp", host)
+			success, metrics, err := c.prober.Probe(ctx, target)
 			if err != nil {
-				c.logger.Log("level", "error", "message", "could not dial host", "host", host, "scrapeID", c.scrapeID, "stack", microerror.Stack(err))
-				c.dialErrorCount.WithLabelValues(host).Inc()
+				c.logger.Error("could not probe host", "host", host, "scrapeID", c.scrapeID, "stack", err.Error())
+				c.errorCount.Inc()
 				return
 			}
-			defer conn.Close()
+			if !success {
+				c.logger.Error("probe of host failed", "host", host, "scrapeID", c.scrapeID)
+				c.dialErrorCount.WithLabelValues(host).Inc()
+			}
+
+			mutex.Lock()
+			probeSuccess[host] = success
+			probeMetrics[host] = metrics
+			mutex.Unlock()
 
-			elapsed := time.Since(start)
-			c.logger.Log("level", "info", "message", "dialed host", "host", host, "scrapeTime", elapsed.Seconds(), "scrapeID", c.scrapeID)
+			if elapsed, ok := metrics["probe_duration_seconds"]; ok {
+				c.logger.Info("probed host", "host", host, "scrapeTime", elapsed, "scrapeID", c.scrapeID)
 
-			c.latencyHistogramVec.Add(host, elapsed.Seconds())
-		}(host)
+				if c.histogramMode == HistogramModeClassic {
+					c.latencyHistogramVec.Add(host, elapsed)
+				} else {
+					c.nativeLatencyVec.WithLabelValues(host).Observe(elapsed)
+				}
+
+				c.latencyMutex.Lock()
+				c.latestByHost[host] = elapsed
+				c.latencyMutex.Unlock()
+			}
+		}(host, targetByHost[host])
 	}
 
 	wg.Wait()
 
-	c.latencyHistogramVec.Ensure(hosts)
+	if c.histogramMode == HistogramModeClassic {
+		c.latencyHistogramVec.Ensure(hosts)
 
-	for host, histogram := range c.latencyHistogramVec.Histograms() {
-		ch <- prometheus.MustNewConstHistogram(
-			c.latencyHistogramDesc,
-			histogram.Count(), histogram.Sum(), histogram.Buckets(),
-			host,
-		)
+		for host, histogram := range c.latencyHistogramVec.Histograms() {
+			ch <- prometheus.MustNewConstHistogram(
+				c.latencyHistogramDesc,
+				histogram.Count(), histogram.Sum(), histogram.Buckets(),
+				host,
+			)
+		}
+	}
+
+	for host, success := range probeSuccess {
+		value := 0.0
+		if success {
+			value = 1.0
+		}
+		ch <- prometheus.MustNewConstMetric(c.probeSuccessDesc, prometheus.GaugeValue, value, host, c.module)
+	}
+
+	for host, metrics := range probeMetrics {
+		if duration, ok := metrics["probe_duration_seconds"]; ok {
+			ch <- prometheus.MustNewConstMetric(c.probeDurationDesc, prometheus.GaugeValue, duration, host, c.module)
+		}
+		if statusCode, ok := metrics["probe_http_status_code"]; ok {
+			ch <- prometheus.MustNewConstMetric(c.probeHTTPStatusCodeDesc, prometheus.GaugeValue, statusCode, host, c.module)
+		}
+	}
+
+	synced := 0.0
+	if c.serviceInformer.HasSynced() && c.endpointsInformer.HasSynced() {
+		synced = 1.0
 	}
+	ch <- prometheus.MustNewConstMetric(c.cacheSyncedDesc, prometheus.GaugeValue, synced)
+	ch <- prometheus.MustNewConstMetric(c.lastUpdateDesc, prometheus.GaugeValue, float64(lastUpdate.Unix()))
 
 	scrapingElapsed := time.Since(scrapingStart)
-	c.logger.Log("level", "info", "message", "collected metrics", "scrapeID", c.scrapeID, "scrapeTime", scrapingElapsed.Seconds())
+	c.logger.Info("collected metrics", "scrapeID", c.scrapeID, "scrapeTime", scrapingElapsed.Seconds())
 }
@@ -0,0 +1,31 @@
+package prober
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/giantswarm/microerror"
+)
+
+// TargetAddress formats a discovered host/port pair into the address form
+// module.Prober expects to receive as Probe's target argument: a bare host
+// for "icmp", a "host:port" pair for "tcp", and a URL for "http". It returns
+// an error if module.Prober cannot probe a host/port pair at all (e.g.
+// "dns", which probes hostnames, not addresses discovered by
+// network.Collector).
+func TargetAddress(module Module, host, port string) (string, error) {
+	switch module.Prober {
+	case "tcp":
+		return net.JoinHostPort(host, port), nil
+	case "icmp":
+		return host, nil
+	case "http":
+		scheme := module.HTTP.Scheme
+		if scheme == "" {
+			scheme = "http"
+		}
+		return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, port)), nil
+	default:
+		return "", microerror.Maskf(invalidConfigError, "prober %q cannot probe a discovered host:port target", module.Prober)
+	}
+}
@@ -0,0 +1,68 @@
+package prober
+
+import "testing"
+
+func TestTargetAddress(t *testing.T) {
+	testCases := []struct {
+		name     string
+		module   Module
+		host     string
+		port     string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "tcp joins host and port",
+			module:   Module{Prober: "tcp"},
+			host:     "10.0.0.1",
+			port:     "8080",
+			expected: "10.0.0.1:8080",
+		},
+		{
+			name:     "icmp drops the port",
+			module:   Module{Prober: "icmp"},
+			host:     "10.0.0.1",
+			port:     "8080",
+			expected: "10.0.0.1",
+		},
+		{
+			name:     "http defaults to the http scheme",
+			module:   Module{Prober: "http"},
+			host:     "10.0.0.1",
+			port:     "8080",
+			expected: "http://10.0.0.1:8080",
+		},
+		{
+			name:     "http honors a configured scheme",
+			module:   Module{Prober: "http", HTTP: HTTPModule{Scheme: "https"}},
+			host:     "10.0.0.1",
+			port:     "8080",
+			expected: "https://10.0.0.1:8080",
+		},
+		{
+			name:    "dns cannot probe a discovered host:port target",
+			module:  Module{Prober: "dns"},
+			host:    "10.0.0.1",
+			port:    "8080",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := TargetAddress(tc.module, tc.host, tc.port)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TargetAddress() returned error: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("TargetAddress() = %q, want %q", got, tc.expected)
+			}
+		})
+	}
+}
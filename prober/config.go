@@ -0,0 +1,84 @@
+package prober
+
+import (
+	"io/ioutil"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the root of the probe configuration file. It defines named
+// modules, each backed by a particular Prober implementation, similar in
+// spirit to blackbox_exporter's module system, plus a list of static targets
+// to probe with those modules.
+type Config struct {
+	Modules map[string]Module `yaml:"modules"`
+	Targets []Target          `yaml:"targets"`
+}
+
+// Target names a single literal probe target, plus the module used to probe
+// it.
+type Target struct {
+	Module string `yaml:"module"`
+	Target string `yaml:"target"`
+}
+
+// Module describes a single, named probe configuration.
+type Module struct {
+	// Prober selects the implementation: "tcp", "http", "icmp" or "dns".
+	Prober string `yaml:"prober"`
+	// Timeout bounds how long a single Probe call may take. It is applied
+	// on top of any deadline the caller's context already carries.
+	Timeout time.Duration `yaml:"timeout"`
+
+	HTTP HTTPModule `yaml:"http,omitempty"`
+	DNS  DNSModule  `yaml:"dns,omitempty"`
+}
+
+// HTTPModule holds the settings specific to the "http" prober.
+type HTTPModule struct {
+	// Scheme is used by TargetAddress to build a URL from a discovered
+	// host/port pair, such as a Service or Endpoint discovered by
+	// network.Collector. It defaults to "http" when empty. The http prober
+	// itself does not read it, so literal targets are requested as given.
+	Scheme           string    `yaml:"scheme,omitempty"`
+	ValidStatusCodes []int     `yaml:"valid_status_codes"`
+	TLSConfig        TLSConfig `yaml:"tls_config"`
+}
+
+// TLSConfig holds the TLS settings used by probers that dial over TLS.
+type TLSConfig struct {
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+}
+
+// DNSModule holds the settings specific to the "dns" prober.
+type DNSModule struct {
+	QueryType string `yaml:"query_type"`
+}
+
+// LoadConfigFile reads and parses a probe configuration file from path.
+func LoadConfigFile(path string) (Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, microerror.Mask(err)
+	}
+
+	var config Config
+	err = yaml.Unmarshal(data, &config)
+	if err != nil {
+		return Config{}, microerror.Mask(err)
+	}
+
+	return config, nil
+}
+
+// Module looks up a named module, returning an error if it is not defined.
+func (c Config) Module(name string) (Module, error) {
+	module, ok := c.Modules[name]
+	if !ok {
+		return Module{}, microerror.Maskf(invalidConfigError, "module %q is not defined", name)
+	}
+
+	return module, nil
+}
@@ -0,0 +1,70 @@
+package prober
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/miekg/dns"
+)
+
+const resolvConfPath = "/etc/resolv.conf"
+
+// dnsProber reports success when the configured question name resolves via
+// the host's configured nameserver.
+type dnsProber struct {
+	queryType  uint16
+	nameserver string
+}
+
+func newDNSProber(module Module) (*dnsProber, error) {
+	queryType := dns.StringToType[module.DNS.QueryType]
+	if queryType == 0 {
+		queryType = dns.TypeA
+	}
+
+	clientConfig, err := dns.ClientConfigFromFile(resolvConfPath)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if len(clientConfig.Servers) == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "no nameservers configured in %s", resolvConfPath)
+	}
+
+	prober := &dnsProber{
+		queryType:  queryType,
+		nameserver: net.JoinHostPort(clientConfig.Servers[0], clientConfig.Port),
+	}
+
+	return prober, nil
+}
+
+func (p *dnsProber) Probe(ctx context.Context, target string) (bool, map[string]float64, error) {
+	start := time.Now()
+
+	message := new(dns.Msg)
+	message.SetQuestion(dns.Fqdn(target), p.queryType)
+
+	client := new(dns.Client)
+
+	response, _, err := client.ExchangeContext(ctx, message, p.nameserver)
+	if err != nil {
+		return false, nil, nil
+	}
+
+	metrics := map[string]float64{
+		"probe_dns_lookup_time_seconds": time.Since(start).Seconds(),
+	}
+
+	return dnsResponseSuccess(response), metrics, nil
+}
+
+// dnsResponseSuccess reports whether response represents a successful
+// lookup. A transport-level error already short-circuits Probe before this
+// is reached; this only needs to distinguish a resolved name from responses
+// such as NXDOMAIN, SERVFAIL or REFUSED, which ExchangeContext returns
+// without an error.
+func dnsResponseSuccess(response *dns.Msg) bool {
+	return response.Rcode == dns.RcodeSuccess && len(response.Answer) > 0
+}
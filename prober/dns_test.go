@@ -0,0 +1,60 @@
+package prober
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestDNSResponseSuccess(t *testing.T) {
+	testCases := []struct {
+		name     string
+		response *dns.Msg
+		expected bool
+	}{
+		{
+			name: "resolved name with an answer",
+			response: &dns.Msg{
+				MsgHdr: dns.MsgHdr{Rcode: dns.RcodeSuccess},
+				Answer: []dns.RR{&dns.A{}},
+			},
+			expected: true,
+		},
+		{
+			name: "nxdomain",
+			response: &dns.Msg{
+				MsgHdr: dns.MsgHdr{Rcode: dns.RcodeNameError},
+			},
+			expected: false,
+		},
+		{
+			name: "servfail",
+			response: &dns.Msg{
+				MsgHdr: dns.MsgHdr{Rcode: dns.RcodeServerFailure},
+			},
+			expected: false,
+		},
+		{
+			name: "refused",
+			response: &dns.Msg{
+				MsgHdr: dns.MsgHdr{Rcode: dns.RcodeRefused},
+			},
+			expected: false,
+		},
+		{
+			name: "success rcode but no answer records",
+			response: &dns.Msg{
+				MsgHdr: dns.MsgHdr{Rcode: dns.RcodeSuccess},
+			},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := dnsResponseSuccess(tc.response); got != tc.expected {
+				t.Errorf("dnsResponseSuccess() = %v, want %v", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package prober
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// httpProber reports success when a GET of the target returns one of the
+// module's configured valid status codes.
+type httpProber struct {
+	client           *http.Client
+	validStatusCodes map[int]struct{}
+}
+
+func newHTTPProber(module Module, dialer *net.Dialer) *httpProber {
+	validStatusCodes := make(map[int]struct{}, len(module.HTTP.ValidStatusCodes))
+	for _, code := range module.HTTP.ValidStatusCodes {
+		validStatusCodes[code] = struct{}{}
+	}
+	if len(validStatusCodes) == 0 {
+		validStatusCodes[http.StatusOK] = struct{}{}
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: dialer.DialContext,
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: module.HTTP.TLSConfig.InsecureSkipVerify,
+			},
+		},
+	}
+
+	return &httpProber{
+		client:           client,
+		validStatusCodes: validStatusCodes,
+	}
+}
+
+func (p *httpProber) Probe(ctx context.Context, target string) (bool, map[string]float64, error) {
+	start := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return false, nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false, nil, nil
+	}
+	defer resp.Body.Close()
+
+	_, success := p.validStatusCodes[resp.StatusCode]
+
+	metrics := map[string]float64{
+		"probe_duration_seconds": time.Since(start).Seconds(),
+		"probe_http_status_code": float64(resp.StatusCode),
+	}
+
+	return success, metrics, nil
+}
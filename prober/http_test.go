@@ -0,0 +1,76 @@
+package prober
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPProberProbe(t *testing.T) {
+	testCases := []struct {
+		name             string
+		statusCode       int
+		validStatusCodes []int
+		expectedSuccess  bool
+	}{
+		{
+			name:             "200 with default valid status codes",
+			statusCode:       http.StatusOK,
+			validStatusCodes: nil,
+			expectedSuccess:  true,
+		},
+		{
+			name:             "404 with default valid status codes",
+			statusCode:       http.StatusNotFound,
+			validStatusCodes: nil,
+			expectedSuccess:  false,
+		},
+		{
+			name:             "404 explicitly allowed",
+			statusCode:       http.StatusNotFound,
+			validStatusCodes: []int{http.StatusNotFound},
+			expectedSuccess:  true,
+		},
+		{
+			name:             "500 not in configured valid status codes",
+			statusCode:       http.StatusInternalServerError,
+			validStatusCodes: []int{http.StatusOK, http.StatusNotFound},
+			expectedSuccess:  false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.statusCode)
+			}))
+			defer server.Close()
+
+			module := Module{
+				HTTP: HTTPModule{ValidStatusCodes: tc.validStatusCodes},
+			}
+			dialer := &net.Dialer{Timeout: 5 * time.Second}
+			prober := newHTTPProber(module, dialer)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			success, metrics, err := prober.Probe(ctx, server.URL)
+			if err != nil {
+				t.Fatalf("Probe() returned error: %v", err)
+			}
+			if success != tc.expectedSuccess {
+				t.Errorf("Probe() success = %v, want %v", success, tc.expectedSuccess)
+			}
+			if metrics["probe_http_status_code"] != float64(tc.statusCode) {
+				t.Errorf("probe_http_status_code = %v, want %v", metrics["probe_http_status_code"], tc.statusCode)
+			}
+			if _, ok := metrics["probe_duration_seconds"]; !ok {
+				t.Error("probe_duration_seconds missing from metrics")
+			}
+		})
+	}
+}
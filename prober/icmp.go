@@ -0,0 +1,117 @@
+package prober
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// icmpSequence is a process-wide counter used to give each concurrent probe
+// a distinct echo sequence number, so a probe can tell its own reply apart
+// from a reply to any other probe sharing the same raw ICMP socket type.
+var icmpSequence uint32
+
+// icmpProber reports success on receiving an ICMP echo reply from the
+// target. It requires CAP_NET_RAW to open the raw socket used to send and
+// receive ICMP packets.
+type icmpProber struct{}
+
+func newICMPProber() *icmpProber {
+	return &icmpProber{}
+}
+
+func (p *icmpProber) Probe(ctx context.Context, target string) (bool, map[string]float64, error) {
+	start := time.Now()
+
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return false, nil, err
+	}
+	defer conn.Close()
+
+	addr, err := net.ResolveIPAddr("ip4", target)
+	if err != nil {
+		return false, nil, err
+	}
+
+	id := os.Getpid() & 0xffff
+	seq := int(atomic.AddUint32(&icmpSequence, 1) & 0xffff)
+
+	message := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Code: 0,
+		Body: &icmp.Echo{
+			ID:   id,
+			Seq:  seq,
+			Data: []byte("net-exporter"),
+		},
+	}
+
+	data, err := message.Marshal(nil)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if _, err := conn.WriteTo(data, addr); err != nil {
+		return false, nil, nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return false, nil, err
+		}
+	}
+
+	// A raw ICMP socket receives every ICMP packet arriving on the host, not
+	// just replies to what we sent, and concurrent probes to other targets
+	// share the same protocol. Keep reading until we see a reply that
+	// actually matches this probe's target and echo ID/sequence, or until
+	// the read deadline above expires.
+	reply := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(reply)
+		if err != nil {
+			return false, nil, nil
+		}
+
+		parsed, err := icmp.ParseMessage(1, reply[:n])
+		if err != nil {
+			continue
+		}
+
+		if !icmpReplyMatches(peer, addr, parsed, id, seq) {
+			continue
+		}
+
+		success := parsed.Type == ipv4.ICMPTypeEchoReply
+
+		metrics := map[string]float64{
+			"probe_duration_seconds": time.Since(start).Seconds(),
+		}
+
+		return success, metrics, nil
+	}
+}
+
+// icmpReplyMatches reports whether a parsed ICMP message read from peer is
+// actually the reply to the echo request sent to target with the given id
+// and seq, rather than some other packet a concurrent probe's reply or
+// unrelated host traffic arriving on the same raw socket.
+func icmpReplyMatches(peer net.Addr, target *net.IPAddr, parsed *icmp.Message, id, seq int) bool {
+	ipAddr, ok := peer.(*net.IPAddr)
+	if !ok || !ipAddr.IP.Equal(target.IP) {
+		return false
+	}
+
+	echo, ok := parsed.Body.(*icmp.Echo)
+	if !ok {
+		return false
+	}
+
+	return echo.ID == id && echo.Seq == seq
+}
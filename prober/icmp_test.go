@@ -0,0 +1,87 @@
+package prober
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestICMPReplyMatches(t *testing.T) {
+	target := &net.IPAddr{IP: net.ParseIP("10.0.0.1")}
+	other := &net.IPAddr{IP: net.ParseIP("10.0.0.2")}
+
+	echoReply := func(id, seq int) *icmp.Message {
+		return &icmp.Message{
+			Type: ipv4.ICMPTypeEchoReply,
+			Body: &icmp.Echo{ID: id, Seq: seq},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		peer     net.Addr
+		target   *net.IPAddr
+		parsed   *icmp.Message
+		id, seq  int
+		expected bool
+	}{
+		{
+			name:     "matching peer, id and seq",
+			peer:     target,
+			target:   target,
+			parsed:   echoReply(1234, 1),
+			id:       1234,
+			seq:      1,
+			expected: true,
+		},
+		{
+			name:     "reply from a different target",
+			peer:     other,
+			target:   target,
+			parsed:   echoReply(1234, 1),
+			id:       1234,
+			seq:      1,
+			expected: false,
+		},
+		{
+			name:     "reply for a different probe's sequence number",
+			peer:     target,
+			target:   target,
+			parsed:   echoReply(1234, 2),
+			id:       1234,
+			seq:      1,
+			expected: false,
+		},
+		{
+			name:     "reply with a different echo id",
+			peer:     target,
+			target:   target,
+			parsed:   echoReply(5678, 1),
+			id:       1234,
+			seq:      1,
+			expected: false,
+		},
+		{
+			name:   "non-echo body, e.g. destination unreachable",
+			peer:   target,
+			target: target,
+			parsed: &icmp.Message{
+				Type: ipv4.ICMPTypeDestinationUnreachable,
+				Body: &icmp.DstUnreach{},
+			},
+			id:       1234,
+			seq:      1,
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := icmpReplyMatches(tc.peer, tc.target, tc.parsed, tc.id, tc.seq); got != tc.expected {
+				t.Errorf("icmpReplyMatches() = %v, want %v", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package prober
+
+import (
+	"context"
+	"net"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Prober probes a single target and reports whether the target responded
+// successfully, along with any metrics the implementation wants to expose
+// alongside probe_success and probe_duration_seconds.
+//
+// A non-nil error indicates the probe itself could not be attempted (bad
+// configuration, a missing capability, ...); a target that is merely
+// unreachable is reported via success == false with a nil error.
+type Prober interface {
+	Probe(ctx context.Context, target string) (success bool, metrics map[string]float64, err error)
+}
+
+// New constructs the Prober implementation named by module.Prober. If
+// module.Timeout is set, each Probe call is bounded by it, on top of any
+// deadline already present on the context passed to Probe.
+func New(module Module, dialer *net.Dialer) (Prober, error) {
+	var prober Prober
+	var err error
+
+	switch module.Prober {
+	case "tcp":
+		prober = newTCPProber(dialer)
+	case "http":
+		prober = newHTTPProber(module, dialer)
+	case "icmp":
+		prober = newICMPProber()
+	case "dns":
+		prober, err = newDNSProber(module)
+	default:
+		return nil, microerror.Maskf(invalidConfigError, "unknown prober %q", module.Prober)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if module.Timeout > 0 {
+		prober = newTimeoutProber(prober, module.Timeout)
+	}
+
+	return prober, nil
+}
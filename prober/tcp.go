@@ -0,0 +1,34 @@
+package prober
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// tcpProber reports success by completing a TCP handshake with the target.
+// This is the behaviour net-exporter originally hard-coded into
+// network.Collector.
+type tcpProber struct {
+	dialer *net.Dialer
+}
+
+func newTCPProber(dialer *net.Dialer) *tcpProber {
+	return &tcpProber{dialer: dialer}
+}
+
+func (p *tcpProber) Probe(ctx context.Context, target string) (bool, map[string]float64, error) {
+	start := time.Now()
+
+	conn, err := p.dialer.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return false, nil, nil
+	}
+	defer conn.Close()
+
+	metrics := map[string]float64{
+		"probe_duration_seconds": time.Since(start).Seconds(),
+	}
+
+	return true, metrics, nil
+}
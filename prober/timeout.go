@@ -0,0 +1,27 @@
+package prober
+
+import (
+	"context"
+	"time"
+)
+
+// timeoutProber wraps a Prober so each Probe call is bounded by timeout, on
+// top of any deadline already present on the context it is given.
+type timeoutProber struct {
+	prober  Prober
+	timeout time.Duration
+}
+
+func newTimeoutProber(prober Prober, timeout time.Duration) *timeoutProber {
+	return &timeoutProber{
+		prober:  prober,
+		timeout: timeout,
+	}
+}
+
+func (p *timeoutProber) Probe(ctx context.Context, target string) (bool, map[string]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	return p.prober.Probe(ctx, target)
+}
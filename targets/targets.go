@@ -0,0 +1,177 @@
+// Package targets probes a static list of literal targets, each against a
+// named prober module, similar in spirit to blackbox_exporter's module
+// system. It is the counterpart to dns.Collector (which probes --hosts) and
+// network.Collector (which probes a discovered Service/Endpoints), for
+// operators who want to point the http or icmp probers at arbitrary hosts
+// configured in the probe configuration file.
+package targets
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/giantswarm/net-exporter/prober"
+)
+
+const defaultScrapeTimeout = 5 * time.Second
+
+// Probe names a single literal target, the module used to probe it, and the
+// Prober instance built from that module.
+type Probe struct {
+	Target string
+	Module string
+	Prober prober.Prober
+}
+
+// Config provides the necessary configuration for creating a Collector.
+type Config struct {
+	Logger     *slog.Logger
+	Registerer prometheus.Registerer
+
+	// ScrapeTimeout bounds how long a single target probe may take during a
+	// Collect call. It defaults to defaultScrapeTimeout when zero.
+	ScrapeTimeout time.Duration
+
+	Probes []Probe
+}
+
+// Collector implements the Collector interface, exposing probe_success and
+// probe_duration_seconds for a static list of configured targets.
+type Collector struct {
+	logger *slog.Logger
+	probes []Probe
+
+	scrapeTimeout time.Duration
+
+	// scrapeID is used to identify logs for a Collect call.
+	scrapeID uint64
+
+	probeSuccessDesc  *prometheus.Desc
+	probeDurationDesc *prometheus.Desc
+
+	errorCount prometheus.Counter
+}
+
+// New creates a Collector, given a Config.
+func New(config Config) (*Collector, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Registerer == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Registerer must not be empty", config)
+	}
+	if len(config.Probes) == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Probes must not be empty", config)
+	}
+
+	scrapeTimeout := config.ScrapeTimeout
+	if scrapeTimeout == 0 {
+		scrapeTimeout = defaultScrapeTimeout
+	}
+
+	errorCount := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: prometheus.BuildFQName("target", "", "error_total"),
+		Help: "Total number of internal errors probing configured targets.",
+	})
+	config.Registerer.MustRegister(errorCount)
+
+	collector := &Collector{
+		logger: config.Logger,
+		probes: config.Probes,
+
+		scrapeTimeout: scrapeTimeout,
+
+		probeSuccessDesc: prometheus.NewDesc(
+			prometheus.BuildFQName("probe", "", "success"),
+			"Displays whether or not the probe was a success.",
+			[]string{"target", "module"},
+			nil,
+		),
+		probeDurationDesc: prometheus.NewDesc(
+			prometheus.BuildFQName("probe", "", "duration_seconds"),
+			"Returns how long the probe took to complete in seconds.",
+			[]string{"target", "module"},
+			nil,
+		),
+
+		errorCount: errorCount,
+	}
+
+	return collector, nil
+}
+
+// Describe implements the Describe method of the Collector interface.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.probeSuccessDesc
+	ch <- c.probeDurationDesc
+}
+
+// Collect implements the Collect method of the Collector interface.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	atomic.AddUint64(&c.scrapeID, 1)
+
+	scrapingStart := time.Now()
+	c.logger.Info("collecting metrics", "scrapeID", c.scrapeID)
+
+	type probeResult struct {
+		success bool
+		metrics map[string]float64
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	results := make(map[int]probeResult, len(c.probes))
+
+	for i, probe := range c.probes {
+		wg.Add(1)
+
+		go func(i int, probe Probe) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), c.scrapeTimeout)
+			defer cancel()
+
+			success, metrics, err := probe.Prober.Probe(ctx, probe.Target)
+			if err != nil {
+				c.logger.Error("could not probe target", "target", probe.Target, "module", probe.Module, "scrapeID", c.scrapeID, "stack", err.Error())
+				c.errorCount.Inc()
+				return
+			}
+			if !success {
+				c.logger.Error("probe of target failed", "target", probe.Target, "module", probe.Module, "scrapeID", c.scrapeID)
+			}
+
+			mutex.Lock()
+			results[i] = probeResult{success: success, metrics: metrics}
+			mutex.Unlock()
+		}(i, probe)
+	}
+
+	wg.Wait()
+
+	for i, probe := range c.probes {
+		result, ok := results[i]
+		if !ok {
+			continue
+		}
+
+		value := 0.0
+		if result.success {
+			value = 1.0
+		}
+		ch <- prometheus.MustNewConstMetric(c.probeSuccessDesc, prometheus.GaugeValue, value, probe.Target, probe.Module)
+
+		if duration, ok := result.metrics["probe_duration_seconds"]; ok {
+			ch <- prometheus.MustNewConstMetric(c.probeDurationDesc, prometheus.GaugeValue, duration, probe.Target, probe.Module)
+		}
+	}
+
+	scrapingElapsed := time.Since(scrapingStart)
+	c.logger.Info("collected metrics", "scrapeID", c.scrapeID, "scrapeTime", scrapingElapsed.Seconds())
+}